@@ -0,0 +1,432 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safehttp
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxSliceLen bounds how many repeated form values Decode will copy
+// into a single slice field when the caller hasn't requested a different
+// limit. It exists so an adversarial request with thousands of repeated keys
+// can't force unbounded allocation.
+const defaultMaxSliceLen = 1024
+
+// DecodeOption configures Decode and DecodeStrict.
+type DecodeOption func(*decodeConfig)
+
+type decodeConfig struct {
+	maxSliceLen int
+}
+
+// MaxSliceLen overrides the default cap (1024 elements) on how many repeated
+// form values Decode will copy into a single slice field, so a handler that
+// legitimately expects more repeated values isn't truncated, or one that
+// wants a tighter bound can guard harder against adversarial payloads.
+func MaxSliceLen(n int) DecodeOption {
+	return func(c *decodeConfig) { c.maxSliceLen = n }
+}
+
+func resolveDecodeConfig(opts []DecodeOption) decodeConfig {
+	cfg := decodeConfig{maxSliceLen: defaultMaxSliceLen}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// decodeError accumulates every conversion or validation failure encountered
+// while decoding a Form into a struct, instead of stopping at the first one.
+type decodeError struct {
+	errs []error
+}
+
+func (e *decodeError) add(err error) {
+	if err != nil {
+		e.errs = append(e.errs, err)
+	}
+}
+
+func (e *decodeError) errOrNil() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return &multiError{errs: e.errs}
+}
+
+// Decode reflectively populates dst, which must be a non-nil pointer to a
+// struct, from the form's parsed values using struct tags. Fields are mapped
+// by a `form:"key"` tag, falling back to the Go field name when the tag is
+// absent. A `binding:"required"` tag rejects a missing value, and the same
+// tag accepts comma-separated constraints `min=`, `max=`, `len=`, `email`,
+// `url` and `in=a|b|c`. Nested structs are addressed with dotted keys
+// (`address.street`); embedded structs without their own `form` tag are
+// promoted into the parent's key space, matching go-macaron's Form binding.
+//
+// All failures are accumulated and returned together as a single error
+// implementing Unwrap() []error, rather than stopping at the first one.
+// Unknown form keys are ignored; use DecodeStrict to reject them instead.
+//
+// Decode never calls SetString or otherwise writes to an unexported field,
+// and caps slice fields at defaultMaxSliceLen (1024) values unless the
+// caller passes MaxSliceLen to configure a different bound.
+func (f *Form) Decode(dst interface{}, opts ...DecodeOption) error {
+	return decodeValues(f.values, dst, false, resolveDecodeConfig(opts))
+}
+
+// DecodeStrict behaves like Decode but additionally rejects any form key
+// that doesn't map to a field, whether by its `form` tag or by its Go field
+// name, returning an error for each one.
+func (f *Form) DecodeStrict(dst interface{}, opts ...DecodeOption) error {
+	return decodeValues(f.values, dst, true, resolveDecodeConfig(opts))
+}
+
+// Decode reflectively populates dst from the multipart form's values. See
+// Form.Decode for the supported tags, options and error semantics. File
+// fields are not populated by Decode; use File or Files instead.
+func (mf *MultipartForm) Decode(dst interface{}, opts ...DecodeOption) error {
+	return decodeValues(mf.values, dst, false, resolveDecodeConfig(opts))
+}
+
+// DecodeStrict behaves like Decode but rejects unknown form keys, as
+// Form.DecodeStrict does.
+func (mf *MultipartForm) DecodeStrict(dst interface{}, opts ...DecodeOption) error {
+	return decodeValues(mf.values, dst, true, resolveDecodeConfig(opts))
+}
+
+func decodeValues(values map[string][]string, dst interface{}, strict bool, cfg decodeConfig) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("safehttp: Decode requires a non-nil pointer to a struct, got %T", dst)
+	}
+	d := &formDecoder{values: values, maxSliceLen: cfg.maxSliceLen, used: map[string]bool{}}
+	errs := &decodeError{}
+	d.decodeStruct(rv.Elem(), "", errs)
+	if strict {
+		for key := range values {
+			if !d.used[key] {
+				errs.add(fmt.Errorf("safehttp: form key %q does not map to any field", key))
+			}
+		}
+	}
+	return errs.errOrNil()
+}
+
+type formDecoder struct {
+	values      map[string][]string
+	maxSliceLen int
+	used        map[string]bool
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	urlType      = reflect.TypeOf(url.URL{})
+)
+
+func (d *formDecoder) decodeStruct(v reflect.Value, prefix string, errs *decodeError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// Unexported field: never written to.
+			continue
+		}
+		fv := v.Field(i)
+
+		tag := field.Tag.Get("form")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if fieldType.Kind() == reflect.Struct && fieldType != timeType && fieldType != urlType {
+			nestedPrefix := name
+			if field.Anonymous && tag == "" {
+				nestedPrefix = prefix
+			} else if prefix != "" {
+				nestedPrefix = prefix + "." + name
+			}
+			if field.Type.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fieldType))
+				}
+				fv = fv.Elem()
+			}
+			d.decodeStruct(fv, nestedPrefix, errs)
+			continue
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		rules := parseBindingTag(field.Tag.Get("binding"))
+		vals, ok := d.values[key]
+		d.used[key] = true
+		if !ok || len(vals) == 0 {
+			if rules.required {
+				errs.add(fmt.Errorf("safehttp: form field %q is required", key))
+			}
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Slice {
+			d.decodeSlice(fv, key, vals, rules, errs)
+			continue
+		}
+
+		if err := d.setScalar(fv, key, vals[0], field.Tag.Get("time_format")); err != nil {
+			errs.add(err)
+			continue
+		}
+		checkRules(key, vals[0], fieldType.Kind(), rules, errs)
+	}
+}
+
+func (d *formDecoder) decodeSlice(fv reflect.Value, key string, vals []string, rules bindingRules, errs *decodeError) {
+	n := len(vals)
+	if n > d.maxSliceLen {
+		n = d.maxSliceLen
+	}
+	elemType := fv.Type().Elem()
+	res := reflect.MakeSlice(fv.Type(), 0, n)
+	for i, raw := range vals[:n] {
+		elem := reflect.New(elemType).Elem()
+		if err := setScalarValue(elem, raw, ""); err != nil {
+			errs.add(fmt.Errorf("safehttp: element %d of form field %q: %w", i, key, err))
+			continue
+		}
+		checkRules(key, raw, elemType.Kind(), rules, errs)
+		res = reflect.Append(res, elem)
+	}
+	fv.Set(res)
+}
+
+func (d *formDecoder) setScalar(fv reflect.Value, key, raw, timeFormat string) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	if err := setScalarValue(fv, raw, timeFormat); err != nil {
+		return fmt.Errorf("safehttp: form field %q: %w", key, err)
+	}
+	return nil
+}
+
+// setScalarValue converts raw into v's type and assigns it. v must be
+// addressable and not a pointer.
+func setScalarValue(v reflect.Value, raw, timeFormat string) error {
+	switch {
+	case v.Type() == timeType:
+		layout := timeFormat
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == durationType:
+		dur, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(dur))
+		return nil
+	case v.Type() == urlType:
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		switch raw {
+		case "true":
+			v.SetBool(true)
+		case "false":
+			v.SetBool(false)
+		default:
+			return fmt.Errorf("value %q is not a boolean", raw)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// bindingRules is the parsed form of a `binding:"..."` struct tag.
+type bindingRules struct {
+	required bool
+	hasMin   bool
+	min      float64
+	hasMax   bool
+	max      float64
+	hasLen   bool
+	length   int
+	email    bool
+	url      bool
+	in       []string
+}
+
+func parseBindingTag(tag string) bindingRules {
+	var r bindingRules
+	if tag == "" {
+		return r
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+		key, val, hasVal := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			r.required = true
+		case "email":
+			r.email = true
+		case "url":
+			r.url = true
+		case "min":
+			if n, err := strconv.ParseFloat(val, 64); hasVal && err == nil {
+				r.hasMin = true
+				r.min = n
+			}
+		case "max":
+			if n, err := strconv.ParseFloat(val, 64); hasVal && err == nil {
+				r.hasMax = true
+				r.max = n
+			}
+		case "len":
+			if n, err := strconv.Atoi(val); hasVal && err == nil {
+				r.hasLen = true
+				r.length = n
+			}
+		case "in":
+			if hasVal {
+				r.in = strings.Split(val, "|")
+			}
+		}
+	}
+	return r
+}
+
+// isNumericKind reports whether kind is one of Go's integer or float kinds,
+// the ones for which min/max in a binding tag compare magnitude rather than
+// string length.
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// checkRules validates raw, the string form of a single value, against the
+// constraints parsed from a binding tag, appending every violation to errs
+// rather than stopping at the first. kind is the Kind of the destination
+// field (or slice element) raw was decoded into, and decides whether min/max
+// compare numeric magnitude or string length; it must not be inferred by
+// trying to parse raw as a number, since a string field can legitimately
+// hold a value that happens to look numeric (e.g. a zip code).
+func checkRules(key, raw string, kind reflect.Kind, r bindingRules, errs *decodeError) {
+	if r.hasLen && len(raw) != r.length {
+		errs.add(fmt.Errorf("safehttp: form field %q must have length %d", key, r.length))
+	}
+	numeric := isNumericKind(kind)
+	if r.hasMin {
+		if numeric {
+			if n, err := strconv.ParseFloat(raw, 64); err == nil && n < r.min {
+				errs.add(fmt.Errorf("safehttp: form field %q must be >= %v", key, r.min))
+			}
+		} else if len(raw) < int(r.min) {
+			errs.add(fmt.Errorf("safehttp: form field %q must have length >= %v", key, r.min))
+		}
+	}
+	if r.hasMax {
+		if numeric {
+			if n, err := strconv.ParseFloat(raw, 64); err == nil && n > r.max {
+				errs.add(fmt.Errorf("safehttp: form field %q must be <= %v", key, r.max))
+			}
+		} else if len(raw) > int(r.max) {
+			errs.add(fmt.Errorf("safehttp: form field %q must have length <= %v", key, r.max))
+		}
+	}
+	if r.email {
+		if _, err := mail.ParseAddress(raw); err != nil {
+			errs.add(fmt.Errorf("safehttp: form field %q is not a valid email address", key))
+		}
+	}
+	if r.url {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" || !schemeAllowed(u.Scheme, defaultURLSchemes) {
+			errs.add(fmt.Errorf("safehttp: form field %q is not a valid http(s) URL", key))
+		}
+	}
+	if len(r.in) > 0 {
+		ok := false
+		for _, allowed := range r.in {
+			if raw == allowed {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			errs.add(fmt.Errorf("safehttp: form field %q must be one of %v", key, r.in))
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safehttp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeMixedSuccessAndFailure(t *testing.T) {
+	type dst struct {
+		Name string `form:"name" binding:"required"`
+		Age  int    `form:"age" binding:"min=0,max=130"`
+		Role string `form:"role" binding:"in=admin|user"`
+		Bio  string `form:"bio"`
+	}
+
+	f := &Form{values: map[string][]string{
+		"name": {"Ada"},
+		"age":  {"200"},
+		"role": {"superuser"},
+	}}
+
+	var got dst
+	err := f.Decode(&got)
+	if err == nil {
+		t.Fatal("Decode() = nil error, want failures for age and role")
+	}
+	if got.Name != "Ada" {
+		t.Errorf("Name = %q, want %q", got.Name, "Ada")
+	}
+
+	msg := err.Error()
+	if !strings.Contains(msg, "age") {
+		t.Errorf("Decode() error = %q, want it to mention the age field", msg)
+	}
+	if !strings.Contains(msg, "role") {
+		t.Errorf("Decode() error = %q, want it to mention the role field", msg)
+	}
+}
+
+func TestDecodeMinMaxUseFieldKindNotRawFormat(t *testing.T) {
+	type dst struct {
+		Code string `form:"code" binding:"min=2"`
+		Age  int    `form:"age" binding:"min=2"`
+	}
+
+	f := &Form{values: map[string][]string{
+		"code": {"5"},
+		"age":  {"5"},
+	}}
+
+	var got dst
+	err := f.Decode(&got)
+	if err == nil || !strings.Contains(err.Error(), "code") {
+		t.Fatalf("Decode() error = %v, want a length failure for the 1-character string %q", err, got.Code)
+	}
+	if strings.Contains(err.Error(), "age") {
+		t.Errorf("Decode() error = %v, want no failure for the numeric field whose value 5 satisfies min=2", err)
+	}
+}
+
+func TestDecodeRequiredMissing(t *testing.T) {
+	type dst struct {
+		Name string `form:"name" binding:"required"`
+	}
+	f := &Form{values: map[string][]string{}}
+	var got dst
+	if err := f.Decode(&got); err == nil {
+		t.Fatal("Decode() = nil, want an error for a missing required field")
+	}
+}
+
+func TestDecodeNestedDottedKeys(t *testing.T) {
+	type Address struct {
+		Street string `form:"street"`
+		Zip    string `form:"zip" binding:"len=5"`
+	}
+	type dst struct {
+		Address Address `form:"address"`
+	}
+
+	f := &Form{values: map[string][]string{
+		"address.street": {"1 Infinite Loop"},
+		"address.zip":    {"9501"},
+	}}
+
+	var got dst
+	err := f.Decode(&got)
+	if got.Address.Street != "1 Infinite Loop" {
+		t.Errorf("Address.Street = %q, want %q", got.Address.Street, "1 Infinite Loop")
+	}
+	if err == nil || !strings.Contains(err.Error(), "address.zip") {
+		t.Errorf("Decode() error = %v, want a length failure for address.zip", err)
+	}
+}
+
+func TestDecodeSliceReportsFailingIndex(t *testing.T) {
+	type dst struct {
+		Tags []int `form:"tags"`
+	}
+	f := &Form{values: map[string][]string{
+		"tags": {"1", "two", "3", "four"},
+	}}
+
+	var got dst
+	err := f.Decode(&got)
+	if err == nil {
+		t.Fatal("Decode() = nil, want errors for the malformed elements")
+	}
+	if want := []int{1, 3}; len(got.Tags) != len(want) || got.Tags[0] != want[0] || got.Tags[1] != want[1] {
+		t.Errorf("Tags = %v, want %v", got.Tags, want)
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "element 1") || !strings.Contains(msg, "element 3") {
+		t.Errorf("Decode() error = %q, want it to identify elements 1 and 3", msg)
+	}
+}
+
+func TestDecodeMaxSliceLenOption(t *testing.T) {
+	type dst struct {
+		Tags []string `form:"tags"`
+	}
+	f := &Form{values: map[string][]string{
+		"tags": {"a", "b", "c", "d", "e"},
+	}}
+
+	var got dst
+	if err := f.Decode(&got, MaxSliceLen(2)); err != nil {
+		t.Fatalf("Decode() = %v, want no error", err)
+	}
+	if want := []string{"a", "b"}; len(got.Tags) != len(want) || got.Tags[0] != want[0] || got.Tags[1] != want[1] {
+		t.Errorf("Tags = %v, want %v after capping at 2 elements", got.Tags, want)
+	}
+}
+
+func TestDecodeBindingURLRejectsDisallowedScheme(t *testing.T) {
+	type dst struct {
+		Link string `form:"link" binding:"url"`
+	}
+	f := &Form{values: map[string][]string{
+		"link": {"javascript://payload/xss"},
+	}}
+
+	var got dst
+	err := f.Decode(&got)
+	if err == nil {
+		t.Fatal("Decode() = nil, want an error for a javascript: scheme URL")
+	}
+	if got.Link != "javascript://payload/xss" {
+		t.Errorf("Link = %q, want the raw string still assigned despite the validation failure", got.Link)
+	}
+
+	f2 := &Form{values: map[string][]string{"link": {"https://example.com"}}}
+	var got2 dst
+	if err := f2.Decode(&got2); err != nil {
+		t.Errorf("Decode() = %v, want no error for an https URL", err)
+	}
+}
+
+func TestDecodeStrictRejectsUnknownKeys(t *testing.T) {
+	type dst struct {
+		Name string `form:"name"`
+	}
+	f := &Form{values: map[string][]string{
+		"name":    {"Ada"},
+		"unknown": {"x"},
+	}}
+
+	var got dst
+	if err := f.Decode(&got); err != nil {
+		t.Errorf("Decode() = %v, want unknown keys ignored by default", err)
+	}
+
+	var got2 dst
+	if err := f.DecodeStrict(&got2); err == nil {
+		t.Fatal("DecodeStrict() = nil, want an error for the unknown key")
+	}
+}
+
+func TestDecodeRequiresPointerToStruct(t *testing.T) {
+	f := &Form{values: map[string][]string{}}
+	var notAStruct int
+	if err := f.Decode(&notAStruct); err == nil {
+		t.Error("Decode(&notAStruct) = nil, want an error")
+	}
+	var notAPointer struct{}
+	if err := f.Decode(notAPointer); err == nil {
+		t.Error("Decode(notAPointer) = nil, want an error for a non-pointer")
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safehttp
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// defaultPerFileLimit and defaultTotalLimit bound the bytes MultipartForm
+// will let a handler read out of an upload before SetLimits is called,
+// guarding against a lying Content-Length exhausting memory.
+const (
+	defaultPerFileLimit = 10 << 20 // 10 MiB
+	defaultTotalLimit   = 32 << 20 // 32 MiB
+	sniffLen            = 512
+)
+
+// SafeFileHeader wraps a *multipart.FileHeader with a size cap and, when a
+// MIME type allow-list was configured via SetLimits, a content-type check:
+// callers can't accidentally stream an unbounded file into their handler,
+// nor a disguised one where an allow-list is set. With no allow-list, the
+// content type is not checked.
+type SafeFileHeader struct {
+	header       *multipart.FileHeader
+	limit        int64
+	allowedTypes []string
+}
+
+// Filename returns the original filename stripped of any directory
+// components and rejects absolute paths and values containing path traversal
+// sequences or NUL bytes, so a malicious "../../etc/passwd\x00" can't be used to write
+// outside an intended directory.
+func (h *SafeFileHeader) Filename() (string, error) {
+	name := h.header.Filename
+	if strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("safehttp: filename %q contains a NUL byte", name)
+	}
+	if strings.Contains(name, "..") {
+		return "", fmt.Errorf("safehttp: filename %q contains a path traversal sequence", name)
+	}
+	base := filepath.Base(filepath.FromSlash(name))
+	if base == "." || base == string(filepath.Separator) || filepath.IsAbs(name) {
+		return "", fmt.Errorf("safehttp: filename %q is not a valid relative file name", name)
+	}
+	return base, nil
+}
+
+// Size returns the size in bytes reported by the client for this file.
+func (h *SafeFileHeader) Size() int64 {
+	return h.header.Size
+}
+
+// checkSize reports an error if the file's declared size exceeds the
+// field's configured limit, so an oversized upload is rejected outright
+// instead of being silently truncated to the limit.
+func (h *SafeFileHeader) checkSize() error {
+	if h.header.Size > h.limit {
+		return fmt.Errorf("safehttp: file %q size %d exceeds limit %d", h.header.Filename, h.header.Size, h.limit)
+	}
+	return nil
+}
+
+// Open returns a reader over the file's content. It first rejects the file
+// outright if its declared size exceeds the field's configured limit; the
+// returned reader is then also wrapped in an io.LimitReader capped at that
+// same limit, which only serves as a backstop against a lying
+// Content-Length, not as the primary enforcement. When the field has a
+// non-empty MIME type allow-list, the content type is validated, against
+// both the client-supplied Content-Type header and a sniff of the first 512
+// bytes, before any data is returned; Open fails if either type isn't in
+// the allow-list. With no allow-list, the content type is not checked.
+func (h *SafeFileHeader) Open() (io.ReadCloser, error) {
+	if err := h.checkSize(); err != nil {
+		return nil, err
+	}
+	f, err := h.header.Open()
+	if err != nil {
+		return nil, err
+	}
+	if len(h.allowedTypes) > 0 {
+		if err := h.checkContentType(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return &limitedFile{r: io.LimitReader(f, h.limit), c: f}, nil
+}
+
+func (h *SafeFileHeader) checkContentType(f multipart.File) error {
+	declared := h.header.Header.Get("Content-Type")
+	if !typeAllowed(declared, h.allowedTypes) {
+		return fmt.Errorf("safehttp: declared content type %q is not allowed", declared)
+	}
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	sniffed := http.DetectContentType(buf[:n])
+	if !typeAllowed(sniffed, h.allowedTypes) {
+		return fmt.Errorf("safehttp: sniffed content type %q is not allowed", sniffed)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return nil
+}
+
+func typeAllowed(contentType string, allowed []string) bool {
+	mediaType := contentType
+	if i := strings.IndexByte(contentType, ';'); i != -1 {
+		mediaType = contentType[:i]
+	}
+	mediaType = strings.TrimSpace(mediaType)
+	for _, a := range allowed {
+		if strings.EqualFold(mediaType, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedFile pairs a size-limited reader with the underlying
+// multipart.File so Close still closes the real file descriptor.
+type limitedFile struct {
+	r io.Reader
+	c io.Closer
+}
+
+func (l *limitedFile) Read(p []byte) (int, error) { return l.r.Read(p) }
+func (l *limitedFile) Close() error               { return l.c.Close() }
+
+// SetLimits configures the per-file and total size caps, and the MIME type
+// allow-list, enforced by File and Files. It must be called before File or
+// Files to take effect on the headers they return. A nil or empty
+// allowedTypes disables content-type checking for subsequently returned
+// SafeFileHeaders.
+func (mf *MultipartForm) SetLimits(perFile, total int64, allowedTypes []string) {
+	mf.perFileLimit = perFile
+	mf.totalLimit = total
+	mf.allowedTypes = allowedTypes
+}
+
+// File returns the first uploaded file for the given form field, wrapped
+// with the size caps and MIME type allow-list configured via SetLimits. If
+// SetLimits was never called, the package's default size caps apply and the
+// content type is not checked. It returns an error if no
+// file was uploaded under that key, if the form's total declared size
+// exceeds the configured total limit, or if this file's declared size
+// exceeds the configured per-file limit.
+func (mf *MultipartForm) File(key string) (*SafeFileHeader, error) {
+	if err := mf.checkTotal(); err != nil {
+		return nil, err
+	}
+	headers, ok := mf.file[key]
+	if !ok || len(headers) == 0 {
+		return nil, fmt.Errorf("safehttp: no file uploaded for field %q", key)
+	}
+	h := mf.wrap(headers[0])
+	if err := h.checkSize(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Files returns every uploaded file for the given form field, each wrapped
+// with the size caps and MIME type allow-list configured via SetLimits, as
+// File does. It
+// returns nil if no file was uploaded under that key, or if the form's
+// total declared size exceeds the configured total limit. Unlike File,
+// Files has no per-element error to report, so a file whose declared size
+// exceeds the per-file limit is still returned; its Open will fail rather
+// than silently truncate the read.
+func (mf *MultipartForm) Files(key string) []*SafeFileHeader {
+	if err := mf.checkTotal(); err != nil {
+		return nil
+	}
+	headers, ok := mf.file[key]
+	if !ok {
+		return nil
+	}
+	res := make([]*SafeFileHeader, 0, len(headers))
+	for _, h := range headers {
+		res = append(res, mf.wrap(h))
+	}
+	return res
+}
+
+// checkTotal reports an error if the declared size of every uploaded file
+// across the whole form exceeds the configured total limit.
+func (mf *MultipartForm) checkTotal() error {
+	limit := mf.totalLimit
+	if limit == 0 {
+		limit = defaultTotalLimit
+	}
+	var total int64
+	for _, headers := range mf.file {
+		for _, h := range headers {
+			total += h.Size
+		}
+	}
+	if total > limit {
+		return fmt.Errorf("safehttp: total uploaded file size %d exceeds limit %d", total, limit)
+	}
+	return nil
+}
+
+func (mf *MultipartForm) wrap(h *multipart.FileHeader) *SafeFileHeader {
+	limit := mf.perFileLimit
+	if limit == 0 {
+		limit = defaultPerFileLimit
+	}
+	return &SafeFileHeader{header: h, limit: limit, allowedTypes: mf.allowedTypes}
+}
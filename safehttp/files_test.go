@@ -0,0 +1,175 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safehttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// newMultipartForm builds a single-file, single-part multipart/form-data
+// body, parses it back with the standard library (so the returned
+// SafeFileHeaders wrap real, readable *multipart.FileHeaders), and wires it
+// into a MultipartForm the way a handler's request parsing would.
+func newMultipartForm(t *testing.T, fieldName, filename, contentType string, content []byte) *MultipartForm {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	hdr := textproto.MIMEHeader{}
+	hdr.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, fieldName, filename))
+	hdr.Set("Content-Type", contentType)
+	part, err := w.CreatePart(hdr)
+	if err != nil {
+		t.Fatalf("CreatePart() = %v", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatalf("part.Write() = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() = %v", err)
+	}
+
+	r := multipart.NewReader(&buf, w.Boundary())
+	form, err := r.ReadForm(10 << 20)
+	if err != nil {
+		t.Fatalf("ReadForm() = %v", err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+
+	return &MultipartForm{Form: Form{values: form.Value}, file: form.File}
+}
+
+func TestSafeFileHeaderFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		wantErr  bool
+		want     string
+	}{
+		{name: "plain", filename: "report.pdf", want: "report.pdf"},
+		{name: "nested directories stripped", filename: "a/b/report.pdf", want: "report.pdf"},
+		{name: "traversal rejected", filename: "../../etc/passwd", wantErr: true},
+		{name: "NUL byte rejected", filename: "report.pdf\x00.exe", wantErr: true},
+		{name: "absolute path rejected", filename: "/etc/passwd", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &SafeFileHeader{header: &multipart.FileHeader{Filename: tc.filename}}
+			got, err := h.Filename()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Filename() = %q, nil, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Filename() error = %v, want nil", err)
+			}
+			if got != tc.want {
+				t.Errorf("Filename() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFileRejectsOversizedUpload(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 1000)
+	mf := newMultipartForm(t, "upload", "data.bin", "application/octet-stream", content)
+	mf.SetLimits(10, 1<<20, nil)
+
+	h, err := mf.File("upload")
+	if err == nil {
+		t.Fatal("File() = nil error, want rejection of an upload that exceeds the per-file limit")
+	}
+	if h != nil {
+		t.Errorf("File() = %v, want a nil SafeFileHeader on rejection", h)
+	}
+}
+
+func TestFilesOpenRejectsOversizedUpload(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 1000)
+	mf := newMultipartForm(t, "upload", "data.bin", "application/octet-stream", content)
+	mf.SetLimits(10, 1<<20, nil)
+
+	files := mf.Files("upload")
+	if len(files) != 1 {
+		t.Fatalf("Files() returned %d headers, want 1", len(files))
+	}
+	if _, err := files[0].Open(); err == nil {
+		t.Error("Open() = nil error, want rejection of an oversized file instead of a silently truncated read")
+	}
+}
+
+func TestFileRejectsWhenTotalLimitExceeded(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 2000)
+	mf := newMultipartForm(t, "upload", "data.bin", "application/octet-stream", content)
+	mf.SetLimits(1<<20, 1000, nil)
+
+	if _, err := mf.File("upload"); err == nil {
+		t.Error("File() = nil error, want rejection when the form's total declared size exceeds the total limit")
+	}
+}
+
+func TestOpenRejectsDisallowedContentType(t *testing.T) {
+	mf := newMultipartForm(t, "upload", "data.png", "image/png", []byte("not actually a png"))
+	mf.SetLimits(1<<20, 1<<20, []string{"image/png"})
+
+	h, err := mf.File("upload")
+	if err != nil {
+		t.Fatalf("File() = %v, want success", err)
+	}
+	if _, err := h.Open(); err == nil {
+		t.Error("Open() = nil error, want rejection since the sniffed content doesn't match image/png")
+	}
+}
+
+func TestOpenAllowsMatchingContentType(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), []byte(strings.Repeat("x", 100))...)
+	mf := newMultipartForm(t, "upload", "data.png", "image/png", png)
+	mf.SetLimits(1<<20, 1<<20, []string{"image/png"})
+
+	h, err := mf.File("upload")
+	if err != nil {
+		t.Fatalf("File() = %v, want success", err)
+	}
+	rc, err := h.Open()
+	if err != nil {
+		t.Fatalf("Open() = %v, want success for a matching content type", err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("ReadAll() = %v", err)
+	}
+	if !bytes.Equal(got, png) {
+		t.Error("Open() content does not match the uploaded bytes")
+	}
+}
+
+func TestFileNoUpload(t *testing.T) {
+	mf := &MultipartForm{file: map[string][]*multipart.FileHeader{}}
+	if _, err := mf.File("missing"); err == nil {
+		t.Error("File() = nil error, want an error when no file was uploaded under that key")
+	}
+	if got := mf.Files("missing"); got != nil {
+		t.Errorf("Files() = %v, want nil when no file was uploaded under that key", got)
+	}
+}
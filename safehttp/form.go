@@ -17,7 +17,11 @@ package safehttp
 import (
 	"fmt"
 	"mime/multipart"
+	"net/mail"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Form contains parsed data either from URL's query or form parameters, part of
@@ -26,14 +30,27 @@ import (
 // the getter functions.
 type Form struct {
 	values map[string][]string
-	err    error
+	errs   []fieldError
+}
+
+// fieldError pairs a form parameter key with an error encountered while
+// parsing or validating it, preserving the order in which errors occurred.
+type fieldError struct {
+	key string
+	err error
+}
+
+// addErr records an error encountered while parsing paramKey, without
+// discarding any error already recorded for another key.
+func (f *Form) addErr(paramKey string, err error) {
+	f.errs = append(f.errs, fieldError{key: paramKey, err: err})
 }
 
 // Int64 checks whether key paramKey maps to any query or form parameter
 // values. In case it does, it will try to convert the first value to a 64-bit
 // integer and return it. If there are no values associated with paramKey, it
 // will return the default value. If the first value is not an integer, it will
-// return the default value and set the Form error field.
+// return the default value and record the error against paramKey.
 func (f *Form) Int64(paramKey string, defaultValue int64) int64 {
 	vals, ok := f.values[paramKey]
 	if !ok {
@@ -41,7 +58,7 @@ func (f *Form) Int64(paramKey string, defaultValue int64) int64 {
 	}
 	paramVal, err := strconv.ParseInt(vals[0], 10, 64)
 	if err != nil {
-		f.err = err
+		f.addErr(paramKey, err)
 		return defaultValue
 	}
 	return paramVal
@@ -51,8 +68,8 @@ func (f *Form) Int64(paramKey string, defaultValue int64) int64 {
 // values. In case it does, it will try to convert the first value to an
 // 64-bit unsigned integer and return it. If there are no values associated with
 // paramKey, it will return the default value. If the first value is not an
-// unsigned integer, it will return the default value and set the Form
-// error field.
+// unsigned integer, it will return the default value and record the error
+// against paramKey.
 func (f *Form) Uint64(paramKey string, defaultValue uint64) uint64 {
 	vals, ok := f.values[paramKey]
 	if !ok {
@@ -60,7 +77,7 @@ func (f *Form) Uint64(paramKey string, defaultValue uint64) uint64 {
 	}
 	paramVal, err := strconv.ParseUint(vals[0], 10, 64)
 	if err != nil {
-		f.err = err
+		f.addErr(paramKey, err)
 		return defaultValue
 	}
 	return paramVal
@@ -81,7 +98,7 @@ func (f *Form) String(paramKey string, defaultValue string) string {
 // values. In case it does, it will try to convert the first value to a float
 // and return it. If there are no values associated with paramKey, it will
 // return the default value. If the first value is not a float, it will return
-// the default value and set the Form error field.
+// the default value and record the error against paramKey.
 func (f *Form) Float64(paramKey string, defaultValue float64) float64 {
 	vals, ok := f.values[paramKey]
 	if !ok {
@@ -89,7 +106,7 @@ func (f *Form) Float64(paramKey string, defaultValue float64) float64 {
 	}
 	paramVal, err := strconv.ParseFloat(vals[0], 64)
 	if err != nil {
-		f.err = err
+		f.addErr(paramKey, err)
 		return defaultValue
 	}
 	return paramVal
@@ -99,7 +116,7 @@ func (f *Form) Float64(paramKey string, defaultValue float64) float64 {
 // values. In case it does, it will try to convert the first value to a boolean
 // and return it. If there are no values associated with paramKey, it will
 // return the default value. If the first value is not a boolean, it will return
-// the default value and set the Form error field.
+// the default value and record the error against paramKey.
 func (f *Form) Bool(paramKey string, defaultValue bool) bool {
 	vals, ok := f.values[paramKey]
 	if !ok {
@@ -111,11 +128,117 @@ func (f *Form) Bool(paramKey string, defaultValue bool) bool {
 	case "false":
 		return false
 	default:
-		f.err = fmt.Errorf("values of form parameter %q not a boolean", paramKey)
+		f.addErr(paramKey, fmt.Errorf("values of form parameter %q not a boolean", paramKey))
+	}
+	return false
+}
+
+// Time checks whether key paramKey maps to any query or form parameter
+// values. In case it does, it will try to parse the first value as a time
+// using the given layout (see time.Parse) and return it. If there are no
+// values associated with paramKey, it will return the default value. If the
+// first value doesn't match layout, it will return the default value and
+// record the error against paramKey.
+func (f *Form) Time(paramKey, layout string, defaultValue time.Time) time.Time {
+	vals, ok := f.values[paramKey]
+	if !ok {
+		return defaultValue
+	}
+	t, err := time.Parse(layout, vals[0])
+	if err != nil {
+		f.addErr(paramKey, err)
+		return defaultValue
+	}
+	return t
+}
+
+// Duration checks whether key paramKey maps to any query or form parameter
+// values. In case it does, it will try to convert the first value to a
+// time.Duration and return it. If there are no values associated with
+// paramKey, it will return the default value. If the first value is not a
+// valid duration, it will return the default value and record the error
+// against paramKey.
+func (f *Form) Duration(paramKey string, defaultValue time.Duration) time.Duration {
+	vals, ok := f.values[paramKey]
+	if !ok {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(vals[0])
+	if err != nil {
+		f.addErr(paramKey, err)
+		return defaultValue
+	}
+	return d
+}
+
+// defaultURLSchemes are the only URL schemes Form.URL accepts unless the
+// caller opts into a different allow-list via URLSchemes. Accepting
+// arbitrary schemes on user input is a known XSS/open-redirect vector.
+var defaultURLSchemes = []string{"http", "https"}
+
+// URL checks whether key paramKey maps to any query or form parameter
+// values. In case it does, it will try to parse the first value as a URL
+// and return it, rejecting any scheme other than http and https. If there
+// are no values associated with paramKey, it will return the default value.
+// If the first value fails to parse or has a disallowed scheme, it will
+// return the default value and record the error against paramKey.
+func (f *Form) URL(paramKey string, defaultValue *url.URL) *url.URL {
+	return f.url(paramKey, defaultURLSchemes, defaultValue)
+}
+
+// URLSchemes behaves like URL but accepts a value whose scheme is in the
+// given allow-list instead of the http/https default. Use this only when a
+// handler deliberately needs a non-HTTP scheme; keep the list as narrow as
+// possible since accepting arbitrary schemes on user input is a known
+// XSS/open-redirect vector.
+func (f *Form) URLSchemes(paramKey string, schemes []string, defaultValue *url.URL) *url.URL {
+	return f.url(paramKey, schemes, defaultValue)
+}
+
+func (f *Form) url(paramKey string, schemes []string, defaultValue *url.URL) *url.URL {
+	vals, ok := f.values[paramKey]
+	if !ok {
+		return defaultValue
+	}
+	u, err := url.Parse(vals[0])
+	if err != nil {
+		f.addErr(paramKey, err)
+		return defaultValue
+	}
+	if !schemeAllowed(u.Scheme, schemes) {
+		f.addErr(paramKey, fmt.Errorf("scheme %q of form parameter %q is not allowed", u.Scheme, paramKey))
+		return defaultValue
+	}
+	return u
+}
+
+func schemeAllowed(scheme string, allowed []string) bool {
+	for _, s := range allowed {
+		if strings.EqualFold(scheme, s) {
+			return true
+		}
 	}
 	return false
 }
 
+// Email checks whether key paramKey maps to any query or form parameter
+// values. In case it does, it will validate the first value against the
+// RFC 5322 address shape and return it. If there are no values associated
+// with paramKey, it will return the default value. If the first value is
+// not a valid email address, it will return the default value and record
+// the error against paramKey.
+func (f *Form) Email(paramKey, defaultValue string) string {
+	vals, ok := f.values[paramKey]
+	if !ok {
+		return defaultValue
+	}
+	if _, err := mail.ParseAddress(vals[0]); err != nil {
+		f.addErr(paramKey, fmt.Errorf("value of form parameter %q is not a valid email address", paramKey))
+		return defaultValue
+	}
+	return vals[0]
+}
+
 func clearSlice(slicePtr interface{}) error {
 	switch vs := slicePtr.(type) {
 	case *[]string:
@@ -128,6 +251,12 @@ func clearSlice(slicePtr interface{}) error {
 		*vs = nil
 	case *[]bool:
 		*vs = nil
+	case *[]time.Time:
+		*vs = nil
+	case *[]time.Duration:
+		*vs = nil
+	case *[]*url.URL:
+		*vs = nil
 	default:
 		return fmt.Errorf("type not supported in Slice call: %T", vs)
 	}
@@ -137,84 +266,165 @@ func clearSlice(slicePtr interface{}) error {
 // Slice checks whether key paramKey maps to any query or form parameters. If it
 // does, it will try to convert them to the type of slice elements slicePtr
 // points to. If there are no values associated with paramKey, it will clear the
-// slice. If type conversion fails at any point, the Form error field will be
-// set and the slice will be cleared.
+// slice. Every element that fails to convert is recorded as a separate error
+// against paramKey, identifying its index, and is omitted from the resulting
+// slice; conversion continues for the remaining elements rather than stopping
+// at the first failure. *[]time.Time elements are parsed with time.RFC3339;
+// *[]*url.URL elements are subject to the same http/https scheme restriction
+// as URL.
 func (f *Form) Slice(slicePtr interface{}, paramKey string) {
 	mapVals, ok := f.values[paramKey]
 	if !ok {
-		f.err = clearSlice(slicePtr)
+		if err := clearSlice(slicePtr); err != nil {
+			f.addErr(paramKey, err)
+		}
 		return
 	}
 	switch values := slicePtr.(type) {
 	case *[]string:
 		res := make([]string, 0, len(mapVals))
-		for _, x := range mapVals {
-			res = append(res, x)
-		}
+		res = append(res, mapVals...)
 		*values = res
 	case *[]int64:
 		res := make([]int64, 0, len(mapVals))
-		for _, x := range mapVals {
+		for i, x := range mapVals {
 			x, err := strconv.ParseInt(x, 10, 64)
 			if err != nil {
-				f.err = err
-				*values = nil
-				return
+				f.addErr(paramKey, fmt.Errorf("element %d of form parameter %q: %w", i, paramKey, err))
+				continue
 			}
 			res = append(res, x)
 		}
 		*values = res
 	case *[]uint64:
 		res := make([]uint64, 0, len(mapVals))
-		for _, x := range mapVals {
+		for i, x := range mapVals {
 			x, err := strconv.ParseUint(x, 10, 64)
 			if err != nil {
-				f.err = err
-				*values = nil
-				return
+				f.addErr(paramKey, fmt.Errorf("element %d of form parameter %q: %w", i, paramKey, err))
+				continue
 			}
 			res = append(res, x)
 		}
 		*values = res
 	case *[]float64:
 		res := make([]float64, 0, len(mapVals))
-		for _, x := range mapVals {
+		for i, x := range mapVals {
 			x, err := strconv.ParseFloat(x, 64)
 			if err != nil {
-				f.err = err
-				*values = nil
-				return
+				f.addErr(paramKey, fmt.Errorf("element %d of form parameter %q: %w", i, paramKey, err))
+				continue
 			}
 			res = append(res, x)
 		}
 		*values = res
 	case *[]bool:
 		res := make([]bool, 0, len(mapVals))
-		for _, x := range mapVals {
+		for i, x := range mapVals {
 			switch x {
 			case "true":
 				res = append(res, true)
 			case "false":
 				res = append(res, false)
 			default:
-				f.err = fmt.Errorf("values of form parameter %q not a boolean", paramKey)
-				*values = nil
-				return
+				f.addErr(paramKey, fmt.Errorf("element %d of form parameter %q not a boolean", i, paramKey))
 			}
 		}
 		*values = res
 
+	case *[]time.Time:
+		res := make([]time.Time, 0, len(mapVals))
+		for i, x := range mapVals {
+			t, err := time.Parse(time.RFC3339, x)
+			if err != nil {
+				f.addErr(paramKey, fmt.Errorf("element %d of form parameter %q: %w", i, paramKey, err))
+				continue
+			}
+			res = append(res, t)
+		}
+		*values = res
+	case *[]time.Duration:
+		res := make([]time.Duration, 0, len(mapVals))
+		for i, x := range mapVals {
+			d, err := time.ParseDuration(x)
+			if err != nil {
+				f.addErr(paramKey, fmt.Errorf("element %d of form parameter %q: %w", i, paramKey, err))
+				continue
+			}
+			res = append(res, d)
+		}
+		*values = res
+	case *[]*url.URL:
+		res := make([]*url.URL, 0, len(mapVals))
+		for i, x := range mapVals {
+			u, err := url.Parse(x)
+			if err != nil {
+				f.addErr(paramKey, fmt.Errorf("element %d of form parameter %q: %w", i, paramKey, err))
+				continue
+			}
+			if !schemeAllowed(u.Scheme, defaultURLSchemes) {
+				f.addErr(paramKey, fmt.Errorf("element %d of form parameter %q: scheme %q is not allowed", i, paramKey, u.Scheme))
+				continue
+			}
+			res = append(res, u)
+		}
+		*values = res
+
 	default:
-		f.err = clearSlice(slicePtr)
+		if err := clearSlice(slicePtr); err != nil {
+			f.addErr(paramKey, err)
+		}
 	}
-	return
 }
 
-// Err returns the value of the Form error field. This will be nil unless an
-// error occurred while accessing a parsed form value. Calling this method will
-// return the last error that occurred while parsing form values.
+// Err returns every error encountered while accessing parsed form values,
+// combined into a single error, or nil if none occurred. The returned error
+// implements Unwrap() []error, so errors.Is and errors.As still match
+// against any of the individual underlying errors.
 func (f *Form) Err() error {
-	return f.err
+	if len(f.errs) == 0 {
+		return nil
+	}
+	errs := make([]error, len(f.errs))
+	for i, fe := range f.errs {
+		errs[i] = fe.err
+	}
+	return &multiError{errs: errs}
+}
+
+// Errors returns every error encountered while accessing parsed form values,
+// keyed by the form parameter that caused it. A key with more than one
+// error, such as a Slice call with several malformed elements, is reported
+// as a single combined error for that key. It returns nil if no error
+// occurred.
+func (f *Form) Errors() map[string]error {
+	if len(f.errs) == 0 {
+		return nil
+	}
+	byKey := map[string][]error{}
+	var order []string
+	for _, fe := range f.errs {
+		if _, ok := byKey[fe.key]; !ok {
+			order = append(order, fe.key)
+		}
+		byKey[fe.key] = append(byKey[fe.key], fe.err)
+	}
+	res := make(map[string]error, len(order))
+	for _, key := range order {
+		errs := byKey[key]
+		if len(errs) == 1 {
+			res[key] = errs[0]
+		} else {
+			res[key] = &multiError{errs: errs}
+		}
+	}
+	return res
+}
+
+// FieldErr returns the error recorded against paramKey, or nil if accessing
+// that key never produced an error.
+func (f *Form) FieldErr(paramKey string) error {
+	return f.Errors()[paramKey]
 }
 
 // MultipartForm extends the Form structure to define a POST, PATCH or PUT
@@ -224,6 +434,8 @@ func (f *Form) Err() error {
 type MultipartForm struct {
 	Form
 	file map[string][]*multipart.FileHeader
-}
 
-// TODO(@mihalimara22): Create getters and tests for the `file` field in MultipartForm
+	perFileLimit int64
+	totalLimit   int64
+	allowedTypes []string
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package safehttp
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormInt64(t *testing.T) {
+	f := &Form{values: map[string][]string{"good": {"42"}, "bad": {"nope"}}}
+
+	if got, want := f.Int64("good", -1), int64(42); got != want {
+		t.Errorf("Int64(%q) = %d, want %d", "good", got, want)
+	}
+	if got, want := f.Int64("missing", 7), int64(7); got != want {
+		t.Errorf("Int64(%q) = %d, want default %d", "missing", got, want)
+	}
+	if got, want := f.Int64("bad", -1), int64(-1); got != want {
+		t.Errorf("Int64(%q) = %d, want default %d", "bad", got, want)
+	}
+	if f.Err() == nil {
+		t.Fatal("Err() = nil, want error after a failed Int64 conversion")
+	}
+}
+
+func TestFormBool(t *testing.T) {
+	f := &Form{values: map[string][]string{"t": {"true"}, "f": {"false"}, "bad": {"yes"}}}
+
+	if got := f.Bool("t", false); !got {
+		t.Errorf("Bool(%q) = false, want true", "t")
+	}
+	if got := f.Bool("f", true); got {
+		t.Errorf("Bool(%q) = true, want false", "f")
+	}
+	if got := f.Bool("missing", true); !got {
+		t.Errorf("Bool(%q) = false, want default true", "missing")
+	}
+	if got := f.Bool("bad", true); got {
+		t.Errorf("Bool(%q) = true, want false on parse failure", "bad")
+	}
+}
+
+func TestFormTimeDuration(t *testing.T) {
+	f := &Form{values: map[string][]string{
+		"when": {"2024-01-02"},
+		"bad":  {"not-a-date"},
+		"ttl":  {"5s"},
+	}}
+
+	want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	if got := f.Time("when", "2006-01-02", time.Time{}); !got.Equal(want) {
+		t.Errorf("Time(%q) = %v, want %v", "when", got, want)
+	}
+	def := time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := f.Time("bad", "2006-01-02", def); !got.Equal(def) {
+		t.Errorf("Time(%q) = %v, want default %v", "bad", got, def)
+	}
+
+	if got, want := f.Duration("ttl", 0), 5*time.Second; got != want {
+		t.Errorf("Duration(%q) = %v, want %v", "ttl", got, want)
+	}
+	if got, want := f.Duration("missing", 3*time.Second), 3*time.Second; got != want {
+		t.Errorf("Duration(%q) = %v, want default %v", "missing", got, want)
+	}
+}
+
+func TestFormURL(t *testing.T) {
+	f := &Form{values: map[string][]string{
+		"safe": {"https://example.com/a"},
+		"evil": {"javascript://payload/xss"},
+	}}
+
+	def := &url.URL{Path: "/default"}
+	got := f.URL("safe", def)
+	if got == def || got.String() != "https://example.com/a" {
+		t.Errorf("URL(%q) = %v, want https://example.com/a", "safe", got)
+	}
+
+	if got := f.URL("evil", def); got != def {
+		t.Errorf("URL(%q) = %v, want default for disallowed scheme", "evil", got)
+	}
+	if f.FieldErr("evil") == nil {
+		t.Error("FieldErr(\"evil\") = nil, want error for disallowed scheme")
+	}
+
+	allowed := f.URLSchemes("evil", []string{"javascript"}, def)
+	if allowed == def {
+		t.Error("URLSchemes with an explicit allow-list should accept the scheme it lists")
+	}
+}
+
+func TestFormEmail(t *testing.T) {
+	f := &Form{values: map[string][]string{
+		"good": {"user@example.com"},
+		"bad":  {"not-an-email"},
+	}}
+
+	if got, want := f.Email("good", ""), "user@example.com"; got != want {
+		t.Errorf("Email(%q) = %q, want %q", "good", got, want)
+	}
+	if got, want := f.Email("bad", "fallback"), "fallback"; got != want {
+		t.Errorf("Email(%q) = %q, want default %q", "bad", got, want)
+	}
+}
+
+func TestFormSliceReportsFailingIndex(t *testing.T) {
+	f := &Form{values: map[string][]string{
+		"nums": {"1", "nope", "3", "also-nope"},
+	}}
+	var nums []int64
+	f.Slice(&nums, "nums")
+
+	if want := []int64{1, 3}; len(nums) != len(want) || nums[0] != want[0] || nums[1] != want[1] {
+		t.Errorf("nums = %v, want %v", nums, want)
+	}
+
+	err := f.FieldErr("nums")
+	if err == nil {
+		t.Fatal("FieldErr(\"nums\") = nil, want a combined error")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "element 1") || !strings.Contains(msg, "element 3") {
+		t.Errorf("FieldErr(\"nums\").Error() = %q, want it to identify elements 1 and 3", msg)
+	}
+}
+
+func TestFormErrorsAggregateAcrossKeys(t *testing.T) {
+	f := &Form{values: map[string][]string{
+		"ok1":  {"1"},
+		"bad1": {"nope"},
+		"ok2":  {"2"},
+		"bad2": {"also-nope"},
+		"ok3":  {"3"},
+	}}
+
+	f.Int64("ok1", 0)
+	f.Int64("bad1", 0)
+	f.Int64("ok2", 0)
+	f.Int64("bad2", 0)
+	f.Int64("ok3", 0)
+
+	errs := f.Errors()
+	if len(errs) != 2 {
+		t.Fatalf("Errors() has %d entries, want 2 (one per failing key): %v", len(errs), errs)
+	}
+	if errs["bad1"] == nil || errs["bad2"] == nil {
+		t.Fatalf("Errors() = %v, want entries for bad1 and bad2", errs)
+	}
+	if errs["ok1"] != nil || errs["ok2"] != nil || errs["ok3"] != nil {
+		t.Fatalf("Errors() = %v, want no entries for successfully parsed keys", errs)
+	}
+
+	combined := f.Err()
+	if combined == nil {
+		t.Fatal("Err() = nil, want a combined error across both failures")
+	}
+	var target *strconv.NumError
+	if !errors.As(combined, &target) {
+		t.Error("errors.As against Err() should still find the underlying *strconv.NumError")
+	}
+}